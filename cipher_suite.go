@@ -0,0 +1,136 @@
+package nex
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+)
+
+// CipherSuite bundles the cryptographic primitives a Client uses to protect PRUDP packets
+type CipherSuite interface {
+	// Seal encrypts a Data packet payload before it goes out on the wire
+	Seal(payload []byte) []byte
+
+	// Open decrypts a Data packet payload read off the wire
+	Open(payload []byte) []byte
+
+	// Sign calculates the packet signature placed in the PRUDP header
+	Sign(packet *Packet) []byte
+
+	// Checksum calculates the trailing packet checksum over data
+	Checksum(data []byte) uint32
+}
+
+// RC4CipherSuite is the default CipherSuite used by PRUDPv0 servers
+type RC4CipherSuite struct {
+	client *Client
+}
+
+// NewRC4CipherSuite returns a CipherSuite bound to client that reproduces
+// the historical RC4 + summed-checksum PRUDPv0 behavior
+func NewRC4CipherSuite(client *Client) *RC4CipherSuite {
+	return &RC4CipherSuite{client: client}
+}
+
+// Seal encrypts payload with the client's RC4 cipher
+func (cipherSuite *RC4CipherSuite) Seal(payload []byte) []byte {
+	ciphered := make([]byte, len(payload))
+	cipherSuite.client.GetCipher().XORKeyStream(ciphered, payload)
+
+	return ciphered
+}
+
+// Open decrypts payload with the client's RC4 decipher
+func (cipherSuite *RC4CipherSuite) Open(payload []byte) []byte {
+	ciphered := make([]byte, len(payload))
+	cipherSuite.client.GetDecipher().XORKeyStream(ciphered, payload)
+
+	return ciphered
+}
+
+// Sign returns an empty signature, as unmodified PRUDPv0 servers don't sign packets
+func (cipherSuite *RC4CipherSuite) Sign(packet *Packet) []byte {
+	return []byte{}
+}
+
+// Checksum sums data in 4 byte steps against the client's signature base, as
+// used by the original PRUDPv0 access keys
+func (cipherSuite *RC4CipherSuite) Checksum(data []byte) uint32 {
+	signatureBase := cipherSuite.client.GetSignatureBase()
+	steps := len(data) / 4
+	var temp uint32
+
+	for i := 0; i < steps; i++ {
+		offset := i * 4
+		temp += binary.LittleEndian.Uint32(data[offset : offset+4])
+	}
+
+	temp &= 0xFFFFFFFF
+
+	buff := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buff, temp)
+
+	checksum := signatureBase
+	checksum += sum(data[len(data)&^3:])
+	checksum += sum(buff)
+
+	return uint32(checksum & 0xFF)
+}
+
+// FriendsCipherSuite is the CipherSuite used by the Friends server access key
+type FriendsCipherSuite struct {
+	RC4CipherSuite
+}
+
+// NewFriendsCipherSuite returns a CipherSuite bound to client that
+// reproduces the Friends server's HMAC-MD5 signature handling
+func NewFriendsCipherSuite(client *Client) *FriendsCipherSuite {
+	return &FriendsCipherSuite{RC4CipherSuite{client: client}}
+}
+
+// Sign calculates the Friends server packet signature
+func (cipherSuite *FriendsCipherSuite) Sign(packet *Packet) []byte {
+	client := cipherSuite.client
+
+	if packet.GetType() == DataPacket {
+		payload := packet.GetPayload()
+
+		if payload == nil || len(payload) <= 0 {
+			signature := NewStreamOut(client.GetServer())
+			signature.WriteUInt32LE(0x12345678)
+
+			return signature.Bytes()
+		}
+
+		key := client.GetSignatureKey()
+		mac := hmac.New(md5.New, key)
+		mac.Write(payload)
+
+		return mac.Sum(nil)[:4]
+	}
+
+	clientConnectionSignature := client.GetClientConnectionSignature()
+
+	if clientConnectionSignature != nil {
+		return clientConnectionSignature
+	}
+
+	return []byte{0x0, 0x0, 0x0, 0x0}
+}
+
+// CipherSuiteForAccessKey returns the CipherSuite a Client should use for
+// accessKey, defaulting to plain RC4 unless the key belongs to a server
+// known to need different signature handling
+func CipherSuiteForAccessKey(accessKey string, client *Client) CipherSuite {
+	if accessKey == "ridfebb9" {
+		return NewFriendsCipherSuite(client)
+	}
+
+	return NewRC4CipherSuite(client)
+}
+
+// CipherSuite returns the CipherSuite client uses to seal, open, sign, and
+// checksum PRUDP packets, selected by its server's access key
+func (client *Client) CipherSuite() CipherSuite {
+	return CipherSuiteForAccessKey(client.GetServer().GetAccessKey(), client)
+}
@@ -0,0 +1,42 @@
+package nex
+
+import "sync"
+
+// Client is a single PRUDP connection tracked by a Server
+type Client struct {
+	mutex sync.Mutex
+
+	reliableStream *ReliableStream
+}
+
+// GetReliableStream returns client's ReliableStream, creating one with the
+// default window size, retransmit timeout, and fragment limit on first use
+func (client *Client) GetReliableStream() *ReliableStream {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.reliableStream == nil {
+		client.reliableStream = NewReliableStream(client)
+	}
+
+	return client.reliableStream
+}
+
+// Close tears down client's per-connection state: it stops any pending
+// reliable-stream retransmit timers and releases its connection ID, if any.
+// Call it when client disconnects so neither outlives the session.
+func (client *Client) Close() {
+	client.mutex.Lock()
+	stream := client.reliableStream
+	client.mutex.Unlock()
+
+	if stream != nil {
+		stream.Close()
+	}
+
+	registry := client.GetServer().ConnectionIDRegistry()
+
+	if connectionID, ok := registry.ConnectionIDFor(client); ok {
+		registry.Unregister(connectionID)
+	}
+}
@@ -0,0 +1,138 @@
+package nex
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"sync"
+)
+
+// connectionIDLength is the size, in bytes, of a PRUDP connection ID option
+const connectionIDLength = 4
+
+// GenerateConnectionID returns a new random, server-chosen opaque
+// connection ID to hand out during a SYN/CONNECT handshake
+func GenerateConnectionID() ([]byte, error) {
+	id := make([]byte, connectionIDLength)
+
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return id, nil
+}
+
+// ConnectionIDRegistry tracks Clients by the opaque connection ID negotiated
+// during their SYN/CONNECT handshake, independently of their current net.UDPAddr
+type ConnectionIDRegistry struct {
+	mutex         sync.RWMutex
+	clients       map[string]*Client
+	connectionIDs map[*Client][]byte
+}
+
+// NewConnectionIDRegistry returns an empty ConnectionIDRegistry
+func NewConnectionIDRegistry() *ConnectionIDRegistry {
+	return &ConnectionIDRegistry{
+		clients:       make(map[string]*Client),
+		connectionIDs: make(map[*Client][]byte),
+	}
+}
+
+// Register associates connectionID with client, replacing any connection ID
+// previously registered for client (e.g. from a retried CONNECT)
+func (registry *ConnectionIDRegistry) Register(connectionID []byte, client *Client) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if previousID, ok := registry.connectionIDs[client]; ok {
+		delete(registry.clients, string(previousID))
+	}
+
+	registry.clients[string(connectionID)] = client
+	registry.connectionIDs[client] = connectionID
+}
+
+// Unregister removes connectionID and its associated Client from the registry
+func (registry *ConnectionIDRegistry) Unregister(connectionID []byte) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if client, ok := registry.clients[string(connectionID)]; ok {
+		delete(registry.connectionIDs, client)
+	}
+
+	delete(registry.clients, string(connectionID))
+}
+
+// Lookup returns the Client registered under connectionID, if any
+func (registry *ConnectionIDRegistry) Lookup(connectionID []byte) (*Client, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	client, ok := registry.clients[string(connectionID)]
+
+	return client, ok
+}
+
+// ConnectionIDFor returns the connection ID previously registered for client, if any
+func (registry *ConnectionIDRegistry) ConnectionIDFor(client *Client) ([]byte, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	connectionID, ok := registry.connectionIDs[client]
+
+	return connectionID, ok
+}
+
+// migrationMAC computes the per-packet, per-address authenticator a
+// connection-migrating packet must carry as its signature
+func migrationMAC(client *Client, connectionID []byte, newAddr *net.UDPAddr, sequenceID uint16) []byte {
+	mac := hmac.New(md5.New, client.GetSignatureKey())
+	mac.Write(connectionID)
+	mac.Write([]byte(newAddr.String()))
+
+	var sequenceIDBytes [2]byte
+	binary.LittleEndian.PutUint16(sequenceIDBytes[:], sequenceID)
+	mac.Write(sequenceIDBytes[:])
+
+	return mac.Sum(nil)[:4]
+}
+
+// Migrate verifies packet's signature against a fresh migrationMAC bound to
+// client, newAddr, and packet's sequence ID, then updates client's UDP
+// address to newAddr and rebinds packet to client so the rest of Decode
+// continues against the established session instead of the throwaway
+// per-address Client that address-based dispatch handed to NewPacketV0.
+// client.SetAddress is responsible for keeping the Server's own
+// address-keyed dispatch table in sync with the new address.
+func (registry *ConnectionIDRegistry) Migrate(client *Client, packet *PacketV0, newAddr *net.UDPAddr) bool {
+	expected := migrationMAC(client, packet.GetConnectionID(), newAddr, packet.GetSequenceID())
+
+	if !hmac.Equal(expected, packet.GetSignature()) {
+		return false
+	}
+
+	client.SetAddress(newAddr)
+	packet.SetSender(client)
+
+	return true
+}
+
+// ConnectionIDsEnabled reports whether server hands out and expects opaque
+// connection IDs for migration, as set by SetConnectionIDsEnabled. Disabled by default.
+func (server *Server) ConnectionIDsEnabled() bool {
+	server.mutex.RLock()
+	defer server.mutex.RUnlock()
+
+	return server.connectionIDsEnabled
+}
+
+// SetConnectionIDsEnabled enables or disables connection ID handling for server
+func (server *Server) SetConnectionIDsEnabled(enabled bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.connectionIDsEnabled = enabled
+}
@@ -0,0 +1,25 @@
+package nex
+
+import "fmt"
+
+// ChecksumMismatchError indicates a PRUDPv0 packet's trailing checksum
+// didn't match the checksum calculated over its body
+type ChecksumMismatchError struct {
+	Expected uint32
+	Actual   uint32
+}
+
+// Error implements the error interface
+func (err *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("[PRUDPv0] calculated checksum (%#x) did not match packet checksum (%#x)", err.Expected, err.Actual)
+}
+
+// packetV0Error formats a PRUDPv0 decode error with the standard "[PRUDPv0] " prefix
+func packetV0Error(message string) error {
+	return fmt.Errorf("[PRUDPv0] %s", message)
+}
+
+// packetV0Errorf formats a PRUDPv0 decode error with printf-style arguments
+func packetV0Errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("[PRUDPv0] "+format, args...)
+}
@@ -0,0 +1,63 @@
+package nex
+
+import (
+	"log"
+	"os"
+)
+
+// Logger is the logging interface a Server uses to report packet-level
+// errors and diagnostics
+type Logger interface {
+	// Error logs a message indicating a packet was dropped or a client
+	// misbehaved
+	Error(message string)
+
+	// Warning logs a message about a recoverable, but noteworthy, condition
+	Warning(message string)
+
+	// Info logs a routine, non-error message
+	Info(message string)
+}
+
+// StandardLogger is the default Logger, backed by the standard library's log package
+type StandardLogger struct {
+	logger *log.Logger
+}
+
+// NewStandardLogger returns a StandardLogger writing to stderr with the
+// standard log package's default flags
+func NewStandardLogger() *StandardLogger {
+	return &StandardLogger{logger: log.New(os.Stderr, "", log.LstdFlags)}
+}
+
+// Error logs message at the error level
+func (standardLogger *StandardLogger) Error(message string) {
+	standardLogger.logger.Println("[ERROR]", message)
+}
+
+// Warning logs message at the warning level
+func (standardLogger *StandardLogger) Warning(message string) {
+	standardLogger.logger.Println("[WARNING]", message)
+}
+
+// Info logs message at the info level
+func (standardLogger *StandardLogger) Info(message string) {
+	standardLogger.logger.Println("[INFO]", message)
+}
+
+// NoopLogger is a Logger that discards every message
+type NoopLogger struct{}
+
+// NewNoopLogger returns a Logger that discards every message
+func NewNoopLogger() *NoopLogger {
+	return &NoopLogger{}
+}
+
+// Error discards message
+func (noopLogger *NoopLogger) Error(message string) {}
+
+// Warning discards message
+func (noopLogger *NoopLogger) Warning(message string) {}
+
+// Info discards message
+func (noopLogger *NoopLogger) Info(message string) {}
@@ -1,17 +1,24 @@
 package nex
 
-import (
-	"crypto/hmac"
-	"crypto/md5"
-	"encoding/binary"
-	"errors"
-	"fmt"
-)
+import "encoding/binary"
 
 // PacketV0 reresents a PRUDPv0 packet
 type PacketV0 struct {
 	Packet
-	checksum uint32
+	checksum     uint32
+	connectionID []byte
+}
+
+// SetConnectionID sets the packet's opaque connection ID option, used for
+// connection migration when the server has that feature enabled
+func (packet *PacketV0) SetConnectionID(connectionID []byte) {
+	packet.connectionID = connectionID
+}
+
+// GetConnectionID returns the packet's opaque connection ID option, or nil
+// if the packet didn't carry one
+func (packet *PacketV0) GetConnectionID() []byte {
+	return packet.connectionID
 }
 
 // SetChecksum sets the packet checksum
@@ -27,10 +34,6 @@ func (packet *PacketV0) GetChecksum() uint32 {
 // Decode decodes the packet
 func (packet *PacketV0) Decode() error {
 
-	if len(packet.Data()) < 9 {
-		return errors.New("[PRUDPv0] Packet length less than header minimum")
-	}
-
 	var checksumSize int
 	var payloadSize uint16
 	var typeFlags uint16
@@ -43,14 +46,19 @@ func (packet *PacketV0) Decode() error {
 
 	stream := NewStreamIn(packet.Data(), packet.GetSender().GetServer())
 
-	packet.SetSource(stream.ReadUInt8())
-	packet.SetDestination(stream.ReadUInt8())
+	header, err := packet.safeRead(stream, 11)
+	if err != nil {
+		return packetV0Errorf("packet length less than header minimum: %w", err)
+	}
+
+	packet.SetSource(header[0])
+	packet.SetDestination(header[1])
 
-	typeFlags = stream.ReadUInt16LE()
+	typeFlags = binary.LittleEndian.Uint16(header[2:4])
 
-	packet.SetSessionID(stream.ReadUInt8())
-	packet.SetSignature(stream.ReadBytesNext(4))
-	packet.SetSequenceID(stream.ReadUInt16LE())
+	packet.SetSessionID(header[4])
+	packet.SetSignature(header[5:9])
+	packet.SetSequenceID(binary.LittleEndian.Uint16(header[9:11]))
 
 	if packet.GetSender().GetServer().GetFlagsVersion() == 0 {
 		packet.SetType(typeFlags & 7)
@@ -61,66 +69,91 @@ func (packet *PacketV0) Decode() error {
 	}
 
 	if _, ok := validTypes[packet.GetType()]; !ok {
-		return errors.New("[PRUDPv0] Packet type not valid type")
+		return packetV0Error("packet type not valid type")
 	}
 
 	if packet.GetType() == SynPacket || packet.GetType() == ConnectPacket {
-		if len(packet.Data()[stream.ByteOffset():]) < 4 {
-			return errors.New("[PRUDPv0] Packet specific data not large enough for connection signature")
+		connectionSignature, err := packet.safeRead(stream, 4)
+		if err != nil {
+			return packetV0Errorf("packet specific data not large enough for connection signature: %w", err)
+		}
+
+		packet.SetConnectionSignature(connectionSignature)
+	}
+
+	if packet.GetType() == ConnectPacket && packet.GetSender().GetServer().ConnectionIDsEnabled() {
+		connectionID, err := GenerateConnectionID()
+		if err != nil {
+			return packetV0Errorf("failed to generate connection id: %w", err)
 		}
 
-		packet.SetConnectionSignature(stream.ReadBytesNext(4))
+		packet.GetSender().GetServer().ConnectionIDRegistry().Register(connectionID, packet.GetSender())
 	}
 
 	if packet.GetType() == DataPacket {
-		if len(packet.Data()[stream.ByteOffset():]) < 1 {
-			return errors.New("[PRUDPv0] Packet specific data not large enough for fragment ID")
+		fragmentID, err := packet.safeRead(stream, 1)
+		if err != nil {
+			return packetV0Errorf("packet specific data not large enough for fragment ID: %w", err)
 		}
 
-		packet.SetFragmentID(stream.ReadUInt8())
+		packet.SetFragmentID(fragmentID[0])
+	}
+
+	connectionIDsEnabled := packet.GetSender().GetServer().ConnectionIDsEnabled()
+
+	// SYN and CONNECT are the handshake itself; the server hasn't assigned
+	// a connection ID yet when it receives them, so neither can carry one.
+	// Every packet after the handshake echoes the ID the CONNECT response handed out.
+	if connectionIDsEnabled && packet.GetType() != SynPacket && packet.GetType() != ConnectPacket {
+		connectionID, err := packet.safeRead(stream, connectionIDLength)
+		if err != nil {
+			return packetV0Errorf("packet specific data not large enough for connection id: %w", err)
+		}
+
+		packet.SetConnectionID(connectionID)
+
+		registry := packet.GetSender().GetServer().ConnectionIDRegistry()
+
+		if establishedClient, ok := registry.Lookup(connectionID); ok && establishedClient != packet.GetSender() {
+			if !registry.Migrate(establishedClient, packet, packet.GetSender().GetAddress()) {
+				return packetV0Error("connection id migration failed signature validation")
+			}
+		}
 	}
 
 	if packet.HasFlag(FlagHasSize) {
-		if len(packet.Data()[stream.ByteOffset():]) < 2 {
-			return errors.New("[PRUDPv0] Packet specific data not large enough for payload size")
+		payloadSizeBytes, err := packet.safeRead(stream, 2)
+		if err != nil {
+			return packetV0Errorf("packet specific data not large enough for payload size: %w", err)
 		}
 
-		payloadSize = stream.ReadUInt16LE()
+		payloadSize = binary.LittleEndian.Uint16(payloadSizeBytes)
 	} else {
 		payloadSize = uint16(len(packet.data) - int(stream.ByteOffset()) - checksumSize)
 	}
 
 	if payloadSize > 0 {
-		if len(packet.Data()[stream.ByteOffset():]) < int(payloadSize) {
-			return errors.New("[PRUDPv0] Packet data length less than payload length")
+		payloadCrypted, err := packet.safeRead(stream, int64(payloadSize))
+		if err != nil {
+			return packetV0Errorf("packet data length less than payload length: %w", err)
 		}
 
-		payloadCrypted := stream.ReadBytesNext(int64(payloadSize))
-
 		packet.SetPayload(payloadCrypted)
 
 		if packet.GetType() == DataPacket {
-			ciphered := make([]byte, payloadSize)
-			packet.GetSender().GetDecipher().XORKeyStream(ciphered, payloadCrypted)
-
-			request, err := NewRMCRequest(ciphered)
-
-			if err != nil {
-				return errors.New("[PRUDPv0] Error parsing RMC request: " + err.Error())
-			}
-
-			packet.rmcRequest = request
+			packet.SetPayload(packet.GetSender().CipherSuite().Open(payloadCrypted))
 		}
 	}
 
-	if len(packet.Data()[stream.ByteOffset():]) < int(checksumSize) {
-		return errors.New("[PRUDPv0] Packet data length less than checksum length")
+	checksumBytes, err := packet.safeRead(stream, int64(checksumSize))
+	if err != nil {
+		return packetV0Errorf("packet data length less than checksum length: %w", err)
 	}
 
 	if checksumSize == 1 {
-		packet.SetChecksum(uint32(stream.ReadUInt8()))
+		packet.SetChecksum(uint32(checksumBytes[0]))
 	} else {
-		packet.SetChecksum(stream.ReadUInt32LE())
+		packet.SetChecksum(binary.LittleEndian.Uint32(checksumBytes))
 	}
 
 	packetBody := stream.Bytes()
@@ -128,12 +161,59 @@ func (packet *PacketV0) Decode() error {
 	calculatedChecksum := packet.calculateChecksum(packetBody[:len(packetBody)-checksumSize])
 
 	if calculatedChecksum != packet.GetChecksum() {
-		fmt.Println("[ERROR] Calculated checksum did not match")
+		err := &ChecksumMismatchError{Expected: calculatedChecksum, Actual: packet.GetChecksum()}
+		packet.GetSender().GetServer().GetLogger().Error(err.Error())
+
+		return err
+	}
+
+	if packet.GetType() == DataPacket {
+		reliableStream := packet.GetSender().GetReliableStream()
+
+		if packet.HasFlag(FlagAck) {
+			reliableStream.HandleAck(packet.GetSequenceID())
+		} else if packet.HasFlag(FlagReliable) {
+			if err := reliableStream.HandleData(packet); err != nil {
+				return err
+			}
+
+			// Takes at most one request, the first one waiting on
+			// reliableStream.Requests(). That is the request this packet
+			// completed only if no earlier request was left undrained;
+			// any further requests stay on the channel for the caller
+			// to drain.
+			select {
+			case request := <-reliableStream.Requests():
+				packet.rmcRequest = request
+			default:
+			}
+		} else if payload := packet.GetPayload(); len(payload) > 0 {
+			// Unreliable Data packets aren't subject to ordering or
+			// retransmission, so they're handed to an RMC request directly
+			// instead of going through the reliable stream
+			request, err := NewRMCRequest(payload)
+			if err != nil {
+				return packetV0Errorf("error parsing RMC request: %w", err)
+			}
+
+			packet.rmcRequest = request
+		}
 	}
 
 	return nil
 }
 
+// safeRead reads n bytes from stream, guarding against a hostile or
+// truncated packet by returning an error instead of letting stream panic
+// when fewer than n bytes remain
+func (packet *PacketV0) safeRead(stream *StreamIn, n int64) ([]byte, error) {
+	if int64(len(packet.Data()[stream.ByteOffset():])) < n {
+		return nil, packetV0Errorf("%d bytes required, only %d remain", n, len(packet.Data()[stream.ByteOffset():]))
+	}
+
+	return stream.ReadBytesNext(n), nil
+}
+
 // Bytes encodes the packet and returns a byte array
 func (packet *PacketV0) Bytes() []byte {
 	if packet.GetType() == DataPacket {
@@ -144,10 +224,7 @@ func (packet *PacketV0) Bytes() []byte {
 			payload := packet.GetPayload()
 
 			if payload != nil || len(payload) > 0 {
-				payloadSize := len(payload)
-
-				encrypted := make([]byte, payloadSize)
-				packet.GetSender().GetCipher().XORKeyStream(encrypted, payload)
+				encrypted := packet.GetSender().CipherSuite().Seal(payload)
 
 				packet.SetPayload(encrypted)
 			}
@@ -203,37 +280,7 @@ func (packet *PacketV0) Bytes() []byte {
 }
 
 func (packet *PacketV0) calculateSignature() []byte {
-	// Friends server handles signatures differently, so check for the Friends server access key
-	if packet.GetSender().GetServer().GetAccessKey() == "ridfebb9" {
-		if packet.GetType() == DataPacket {
-			payload := packet.GetPayload()
-
-			if payload == nil || len(payload) <= 0 {
-				signature := NewStreamOut(packet.GetSender().GetServer())
-				signature.WriteUInt32LE(0x12345678)
-
-				return signature.Bytes()
-			}
-
-			key := packet.GetSender().GetSignatureKey()
-			cipher := hmac.New(md5.New, key)
-			cipher.Write(payload)
-
-			return cipher.Sum(nil)[:4]
-		}
-
-		clientConnectionSignature := packet.GetSender().GetClientConnectionSignature()
-
-		if clientConnectionSignature != nil {
-			return clientConnectionSignature
-		}
-
-		return []byte{0x0, 0x0, 0x0, 0x0}
-	}
-
-	// Normal signature handling
-
-	return []byte{}
+	return packet.GetSender().CipherSuite().Sign(&packet.Packet)
 }
 
 func (packet *PacketV0) encodeOptions() []byte {
@@ -253,6 +300,15 @@ func (packet *PacketV0) encodeOptions() []byte {
 		stream.WriteUInt8(packet.GetFragmentID())
 	}
 
+	// The server has nothing to hand out on SYN; the connection ID is
+	// assigned while handling CONNECT and echoed on every packet after that
+	if packet.GetType() != SynPacket && packet.GetSender().GetServer().ConnectionIDsEnabled() {
+		if connectionID, ok := packet.GetSender().GetServer().ConnectionIDRegistry().ConnectionIDFor(packet.GetSender()); ok {
+			stream.Grow(connectionIDLength)
+			stream.WriteBytesNext(connectionID)
+		}
+	}
+
 	if packet.HasFlag(FlagHasSize) {
 		payload := packet.GetPayload()
 
@@ -267,25 +323,7 @@ func (packet *PacketV0) encodeOptions() []byte {
 }
 
 func (packet *PacketV0) calculateChecksum(data []byte) uint32 {
-	signatureBase := packet.GetSender().GetSignatureBase()
-	steps := len(data) / 4
-	var temp uint32
-
-	for i := 0; i < steps; i++ {
-		offset := i * 4
-		temp += binary.LittleEndian.Uint32(data[offset : offset+4])
-	}
-
-	temp &= 0xFFFFFFFF
-
-	buff := make([]byte, 4)
-	binary.LittleEndian.PutUint32(buff, temp)
-
-	checksum := signatureBase
-	checksum += sum(data[len(data) & ^3:])
-	checksum += sum(buff)
-
-	return uint32(checksum & 0xFF)
+	return packet.GetSender().CipherSuite().Checksum(data)
 }
 
 // NewPacketV0 returns a new PRUDPv0 packet
@@ -297,7 +335,7 @@ func NewPacketV0(client *Client, data []byte) (*PacketV0, error) {
 		err := packetv0.Decode()
 
 		if err != nil {
-			return &PacketV0{}, errors.New("[PRUDPv0] Error decoding packet data: " + err.Error())
+			return &PacketV0{}, packetV0Errorf("error decoding packet data: %w", err)
 		}
 	}
 
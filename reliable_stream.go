@@ -0,0 +1,268 @@
+package nex
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultReliableWindowSize is the default size of the inbound sliding window
+	DefaultReliableWindowSize = 64
+
+	// DefaultRetransmitTimeout is the default ACK wait before resending an outgoing Data packet
+	DefaultRetransmitTimeout = 500 * time.Millisecond
+
+	// DefaultMaxFragments is the default limit on buffered fragments per RMC request
+	DefaultMaxFragments = 32
+
+	// DefaultMaxRetransmits is the default number of times an unacknowledged
+	// outgoing Data packet is resent before it's given up on
+	DefaultMaxRetransmits = 5
+)
+
+// pendingPacket is an outgoing Data packet awaiting acknowledgement
+type pendingPacket struct {
+	packet  *PacketV0
+	timer   *time.Timer
+	retries int
+}
+
+// ReliableStream implements reliable, in-order delivery of PRUDPv0 Data packets for a single Client
+type ReliableStream struct {
+	client *Client
+
+	windowSize        int
+	retransmitTimeout time.Duration
+	maxFragments      int
+	maxRetransmits    int
+
+	mutex sync.Mutex
+
+	outgoing map[uint16]*pendingPacket
+
+	nextSequenceID uint16
+	windowStarted  bool
+	incoming       map[uint16]*PacketV0
+	fragments      [][]byte
+
+	requests chan *RMCRequest
+}
+
+// NewReliableStream returns a ReliableStream for client configured with the
+// default window size, retransmit timeout, fragment limit, and retry limit
+func NewReliableStream(client *Client) *ReliableStream {
+	return &ReliableStream{
+		client:            client,
+		windowSize:        DefaultReliableWindowSize,
+		retransmitTimeout: DefaultRetransmitTimeout,
+		maxFragments:      DefaultMaxFragments,
+		maxRetransmits:    DefaultMaxRetransmits,
+		outgoing:          make(map[uint16]*pendingPacket),
+		incoming:          make(map[uint16]*PacketV0),
+		requests:          make(chan *RMCRequest, DefaultReliableWindowSize),
+	}
+}
+
+// Requests returns the channel completed RMC requests are delivered on as
+// their terminating fragment is reassembled. A request is pushed here the
+// moment it completes, regardless of which packet's HandleData call caused
+// the sliding window to advance far enough to finish it.
+func (stream *ReliableStream) Requests() <-chan *RMCRequest {
+	return stream.requests
+}
+
+// SetWindowSize overrides the size of the inbound sliding window
+func (stream *ReliableStream) SetWindowSize(size int) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	stream.windowSize = size
+}
+
+// SetRetransmitTimeout overrides how long an unacknowledged outgoing packet
+// is kept before being resent
+func (stream *ReliableStream) SetRetransmitTimeout(timeout time.Duration) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	stream.retransmitTimeout = timeout
+}
+
+// SetMaxFragments overrides how many fragments may accumulate for a single
+// RMC request before it is discarded
+func (stream *ReliableStream) SetMaxFragments(max int) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	stream.maxFragments = max
+}
+
+// SetMaxRetransmits overrides how many times an unacknowledged outgoing
+// packet is resent before it's given up on
+func (stream *ReliableStream) SetMaxRetransmits(max int) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	stream.maxRetransmits = max
+}
+
+// Send queues packet for reliable delivery, sending it immediately and
+// scheduling a retransmit if it isn't acknowledged before the configured timeout
+func (stream *ReliableStream) Send(packet *PacketV0) {
+	stream.mutex.Lock()
+
+	sequenceID := packet.GetSequenceID()
+
+	pending := &pendingPacket{packet: packet}
+	pending.timer = time.AfterFunc(stream.retransmitTimeout, func() {
+		stream.retransmit(sequenceID)
+	})
+
+	stream.outgoing[sequenceID] = pending
+
+	stream.mutex.Unlock()
+
+	stream.client.GetServer().Send(packet)
+}
+
+// retransmit resends the outgoing packet for sequenceID, if it's still
+// waiting on an ACK and hasn't exhausted its retry limit, and reschedules itself
+func (stream *ReliableStream) retransmit(sequenceID uint16) {
+	stream.mutex.Lock()
+
+	pending, ok := stream.outgoing[sequenceID]
+	if !ok {
+		stream.mutex.Unlock()
+		return
+	}
+
+	pending.retries++
+
+	if pending.retries > stream.maxRetransmits {
+		delete(stream.outgoing, sequenceID)
+		stream.mutex.Unlock()
+		return
+	}
+
+	pending.timer = time.AfterFunc(stream.retransmitTimeout, func() {
+		stream.retransmit(sequenceID)
+	})
+
+	stream.mutex.Unlock()
+
+	stream.client.GetServer().Send(pending.packet)
+}
+
+// Close stops every pending outgoing packet's retransmit timer. Call it
+// when client disconnects or migrates away for good, so packets it never
+// acknowledged stop retransmitting forever.
+func (stream *ReliableStream) Close() {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	for sequenceID, pending := range stream.outgoing {
+		pending.timer.Stop()
+		delete(stream.outgoing, sequenceID)
+	}
+}
+
+// HandleAck clears the outgoing packet acknowledged by sequenceID, if any,
+// and stops its retransmit timer
+func (stream *ReliableStream) HandleAck(sequenceID uint16) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	pending, ok := stream.outgoing[sequenceID]
+	if !ok {
+		return
+	}
+
+	pending.timer.Stop()
+	delete(stream.outgoing, sequenceID)
+}
+
+// HandleData buffers an incoming Data packet in the sliding window and
+// reassembles any packets that are now next in sequence, in order. Packets
+// outside the window are dropped silently, relying on the sender's retransmit.
+//
+// Any requests completed while advancing the window are sent to Requests()
+// only after stream.mutex is released, so a slow or absent consumer blocks
+// this call rather than every other call into stream.
+func (stream *ReliableStream) HandleData(packet *PacketV0) error {
+	stream.mutex.Lock()
+
+	sequenceID := packet.GetSequenceID()
+
+	if !stream.windowStarted {
+		stream.nextSequenceID = sequenceID
+		stream.windowStarted = true
+	}
+
+	if int(sequenceID-stream.nextSequenceID) >= stream.windowSize {
+		stream.mutex.Unlock()
+		return nil
+	}
+
+	stream.incoming[sequenceID] = packet
+
+	var completed []*RMCRequest
+
+	for {
+		next, ok := stream.incoming[stream.nextSequenceID]
+		if !ok {
+			break
+		}
+
+		delete(stream.incoming, stream.nextSequenceID)
+		stream.nextSequenceID++
+
+		request, err := stream.reassemble(next)
+		if err != nil {
+			stream.mutex.Unlock()
+			return err
+		}
+
+		if request != nil {
+			completed = append(completed, request)
+		}
+	}
+
+	stream.mutex.Unlock()
+
+	for _, request := range completed {
+		stream.requests <- request
+	}
+
+	return nil
+}
+
+// reassemble appends packet's payload to the pending fragment buffer and,
+// once a terminating fragment (FragmentID == 0) arrives, parses the
+// complete buffer into an RMCRequest. Returns a nil request, nil error for
+// a non-terminating fragment.
+func (stream *ReliableStream) reassemble(packet *PacketV0) (*RMCRequest, error) {
+	if len(stream.fragments) >= stream.maxFragments {
+		stream.fragments = nil
+		return nil, packetV0Error("too many fragments buffered for a single RMC request")
+	}
+
+	stream.fragments = append(stream.fragments, packet.GetPayload())
+
+	if packet.GetFragmentID() != 0 {
+		return nil, nil
+	}
+
+	var buffer []byte
+	for _, fragment := range stream.fragments {
+		buffer = append(buffer, fragment...)
+	}
+
+	stream.fragments = nil
+
+	request, err := NewRMCRequest(buffer)
+	if err != nil {
+		return nil, packetV0Errorf("error parsing RMC request: %w", err)
+	}
+
+	return request, nil
+}
@@ -0,0 +1,46 @@
+package nex
+
+import "sync"
+
+// Server is a PRUDP listener and the settings its connecting Clients negotiate
+type Server struct {
+	mutex sync.RWMutex
+
+	logger               Logger
+	connectionIDsEnabled bool
+	connectionIDRegistry *ConnectionIDRegistry
+}
+
+// ConnectionIDRegistry returns server's ConnectionIDRegistry, creating an
+// empty one on first use
+func (server *Server) ConnectionIDRegistry() *ConnectionIDRegistry {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if server.connectionIDRegistry == nil {
+		server.connectionIDRegistry = NewConnectionIDRegistry()
+	}
+
+	return server.connectionIDRegistry
+}
+
+// GetLogger returns the Logger set for server via SetLogger, or a
+// StandardLogger if none was set
+func (server *Server) GetLogger() Logger {
+	server.mutex.RLock()
+	defer server.mutex.RUnlock()
+
+	if server.logger == nil {
+		return NewStandardLogger()
+	}
+
+	return server.logger
+}
+
+// SetLogger sets the Logger server uses to report packet-level errors and diagnostics
+func (server *Server) SetLogger(logger Logger) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.logger = logger
+}